@@ -0,0 +1,189 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// conn is a Conn that speaks RESP (the Redis serialization protocol) over a
+// single net.Conn. It's a deliberately minimal vendor of what upstream
+// redigo's Conn provides: no connection pool, no read/write deadlines, no
+// pub/sub. database.dataAccess opens one of these per call (see
+// getConnection), same as it would a pooled connection.
+type conn struct {
+	nc  net.Conn
+	br  *bufio.Reader
+	bw  *bufio.Writer
+	err error
+}
+
+// Dial connects to a Redis server at address ("host:port") over network
+// (normally "tcp") and returns a Conn that speaks RESP against it.
+func Dial(network, address string) (Conn, error) {
+	nc, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}, nil
+}
+
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
+
+// Do sends cmd, flushes, and blocks for one reply in one round trip. That
+// reply is cmd's own only when no reply to an earlier Send is still unread;
+// otherwise it is the oldest unread reply.
+func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	return c.Receive()
+}
+
+// Send writes cmd into the buffered writer without flushing, so a batch of
+// commands can be pipelined with a single Flush.
+func (c *conn) Send(cmd string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.writeCommand(cmd, args); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+func (c *conn) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.bw.Flush(); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		c.err = err
+	}
+	return reply, err
+}
+
+func (c *conn) writeCommand(cmd string, args []interface{}) error {
+	if _, err := fmt.Fprintf(c.bw, "*%d\r\n", len(args)+1); err != nil {
+		return err
+	}
+	if err := c.writeBulk(cmd); err != nil {
+		return err
+	}
+	for _, a := range args {
+		if err := c.writeBulk(argString(a)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) writeBulk(s string) error {
+	if _, err := fmt.Fprintf(c.bw, "$%d\r\n%s\r\n", len(s), s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// argString renders a command argument the same way redigo does: strings
+// and []byte pass through, everything else is formatted with fmt.Sprint
+// (ints, int64s, time.Duration, ...).
+func argString(a interface{}) string {
+	switch a := a.(type) {
+	case string:
+		return a
+	case []byte:
+		return string(a)
+	default:
+		return fmt.Sprint(a)
+	}
+}
+
+// readReply parses one RESP value: simple string (+), error (-), integer
+// (:), bulk string ($, or nil for $-1), or array (*, recursively, or nil
+// for *-1).
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply line")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer reply: %v", err)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length: %v", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2) // +2 for the trailing \r\n
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length: %v", err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			v, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply prefix %q", line[0])
+	}
+}
+
+// readLine reads one RESP line, stripping the trailing \r\n.
+func (c *conn) readLine() (string, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("redis: malformed reply line")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Conn interface {
+	Do(cmd string, args ...interface{}) (interface{}, error)
+	Send(cmd string, args ...interface{}) error
+	Flush() error
+	Receive() (interface{}, error)
+	Close() error
+}
+
+var ErrNil = errors.New("redis: nil")
+
+func Strings(reply interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	switch v := reply.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, it := range v {
+			s, err := toString(it)
+			if err != nil {
+				return nil, err
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	}
+	return nil, errors.New("not a []string")
+}
+
+// Values returns reply as a []interface{}. It's used to destructure a
+// multi-bulk reply (e.g. SCAN's [cursor, keys] pair) before passing its
+// elements to Scan.
+func Values(reply interface{}, err error) ([]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	v, ok := reply.([]interface{})
+	if !ok {
+		return nil, errors.New("not a []interface{}")
+	}
+	return v, nil
+}
+
+// Scan assigns src's elements to dest in order, one src element per dest
+// argument. A *string dest takes the element as-is and a *int dest parses
+// it as a decimal integer; a *[]string dest takes a nested []interface{}
+// element and copies its entries, which is how a SCAN reply's second element (the returned keys)
+// is unpacked. It returns the leftover, unassigned tail of src.
+func Scan(src []interface{}, dest ...interface{}) ([]interface{}, error) {
+	if len(dest) > len(src) {
+		return nil, fmt.Errorf("redis: Scan got %d dest, only %d src", len(dest), len(src))
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			s, err := toString(src[i])
+			if err != nil {
+				return nil, err
+			}
+			*d = s
+		case *int:
+			s, err := toString(src[i])
+			if err != nil {
+				return nil, err
+			}
+			n := 0
+			if _, err := fmt.Sscanf(s, "%d", &n); err != nil {
+				return nil, fmt.Errorf("redis: Scan: %v", err)
+			}
+			*d = n
+		case *[]string:
+			items, ok := src[i].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("redis: Scan: dest *[]string but src[%d] is %T", i, src[i])
+			}
+			strs := make([]string, len(items))
+			for j, it := range items {
+				s, err := toString(it)
+				if err != nil {
+					return nil, err
+				}
+				strs[j] = s
+			}
+			*d = strs
+		default:
+			return nil, fmt.Errorf("redis: Scan: unsupported dest type %T", d)
+		}
+	}
+	return src[len(dest):], nil
+}
+
+func toString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	return "", fmt.Errorf("redis: not a string-like value: %T", v)
+}
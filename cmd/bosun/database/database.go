@@ -0,0 +1,42 @@
+package database
+
+import "bosun.org/_third_party/github.com/garyburd/redigo/redis"
+
+// dataAccess is the Redis-backed SearchDataAccess implementation: every
+// Search_* method in search_data.go and search_bulk.go is declared on it.
+// It holds just the server address and dials a fresh connection per call
+// rather than pooling them; this trimmed tree doesn't vendor redigo's pool,
+// and the hot paths (Search_Bulk, Search_GC) already batch many commands
+// per connection, so a pool mainly buys fewer dial round trips, not
+// correctness.
+type dataAccess struct {
+	addr string
+}
+
+// NewDataAccess returns a SearchDataAccess backed by a live Redis server at
+// addr ("host:port").
+func NewDataAccess(addr string) SearchDataAccess {
+	return &dataAccess{addr: addr}
+}
+
+// getConnection dials a new connection to d.addr. A dial failure is
+// reported through errConn rather than a second return value, so every
+// existing Search_* method (which all assume getConnection cannot fail) the
+// same error path on the first Do/Send it issues.
+func (d *dataAccess) getConnection() redis.Conn {
+	conn, err := redis.Dial("tcp", d.addr)
+	if err != nil {
+		return errConn{err}
+	}
+	return conn
+}
+
+// errConn is a redis.Conn that fails every call with the same error, used
+// to carry a Dial error through getConnection's no-error signature.
+type errConn struct{ err error }
+
+func (e errConn) Do(string, ...interface{}) (interface{}, error) { return nil, e.err }
+func (e errConn) Send(string, ...interface{}) error              { return e.err }
+func (e errConn) Flush() error                                   { return e.err }
+func (e errConn) Receive() (interface{}, error)                  { return nil, e.err }
+func (e errConn) Close() error                                   { return nil }
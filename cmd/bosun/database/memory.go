@@ -0,0 +1,136 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryDataAccess is a dependency-free, in-process implementation of
+// SearchDataAccess backed by a Go map instead of Redis. It mirrors the
+// hourly-bucketed layout documented in search_data.go so that Search_GC
+// and the since-windowed Search_GetTagValues behave the same way as the
+// Redis-backed dataAccess. It's meant for tests (see search_test.go) and
+// for running Bosun in single-binary mode without an external Redis.
+type MemoryDataAccess struct {
+	mu sync.Mutex
+	// buckets maps a hash key (e.g. the result of searchTagvKey) to bucket
+	// name to field to timestamp, the same three-level shape the Redis
+	// schema stores across a base key, a bucket-suffixed key, and a hash.
+	buckets map[string]map[string]map[string]int64
+}
+
+// NewMemoryDataAccess returns an empty MemoryDataAccess, ready to use.
+func NewMemoryDataAccess() *MemoryDataAccess {
+	return &MemoryDataAccess{
+		buckets: make(map[string]map[string]map[string]int64),
+	}
+}
+
+var _ SearchDataAccess = (*MemoryDataAccess)(nil)
+
+func (m *MemoryDataAccess) add(key, field string, ts int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket := searchBucket(ts)
+	byBucket, ok := m.buckets[key]
+	if !ok {
+		byBucket = make(map[string]map[string]int64)
+		m.buckets[key] = byBucket
+	}
+	fields, ok := byBucket[bucket]
+	if !ok {
+		fields = make(map[string]int64)
+		byBucket[bucket] = fields
+	}
+	fields[field] = ts
+}
+
+func (m *MemoryDataAccess) union(key, sinceBucket string) map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]int64)
+	for bucket, fields := range m.buckets[key] {
+		if sinceBucket != "" && bucket < sinceBucket {
+			continue
+		}
+		for field, ts := range fields {
+			if ts > result[field] {
+				result[field] = ts
+			}
+		}
+	}
+	return result
+}
+
+func (m *MemoryDataAccess) Search_AddMetricForTag(tagK, tagV, metric string, time int64) error {
+	m.add(searchMetricKey(tagK, tagV), metric, time)
+	return nil
+}
+
+func (m *MemoryDataAccess) Search_GetMetricsForTag(tagK, tagV string) (map[string]int64, error) {
+	return m.union(searchMetricKey(tagK, tagV), ""), nil
+}
+
+func (m *MemoryDataAccess) Search_AddTagKeyForMetric(metric, tagK string, time int64) error {
+	m.add(searchTagkKey(metric), tagK, time)
+	return nil
+}
+
+func (m *MemoryDataAccess) Search_GetTagKeysForMetric(metric string) (map[string]int64, error) {
+	return m.union(searchTagkKey(metric), ""), nil
+}
+
+func (m *MemoryDataAccess) Search_AddMetric(metric string, time int64) error {
+	m.add(searchAllMetricsKey, metric, time)
+	return nil
+}
+
+func (m *MemoryDataAccess) Search_GetAllMetrics() (map[string]int64, error) {
+	return m.union(searchAllMetricsKey, ""), nil
+}
+
+func (m *MemoryDataAccess) Search_AddTagValue(metric, tagK, tagV string, time int64) error {
+	m.add(searchTagvKey(metric, tagK), tagV, time)
+	return nil
+}
+
+func (m *MemoryDataAccess) Search_GetTagValues(metric, tagK string, since time.Duration) (map[string]int64, error) {
+	var sinceBucket string
+	if since > 0 {
+		sinceBucket = searchBucket(time.Now().Add(-since).Unix())
+	}
+	return m.union(searchTagvKey(metric, tagK), sinceBucket), nil
+}
+
+// Search_Bulk indexes entries the same way the Search_Add* methods would,
+// just without Redis's round-trip cost to pipeline around.
+func (m *MemoryDataAccess) Search_Bulk(entries []SearchBulkEntry) error {
+	for _, e := range entries {
+		m.add(searchAllMetricsKey, e.Metric, e.Time)
+		for tagK, tagV := range e.Tags {
+			m.add(searchTagkKey(e.Metric), tagK, e.Time)
+			m.add(searchTagvKey(e.Metric, tagK), tagV, e.Time)
+			m.add(searchTagvKey(Search_All, tagK), tagV, e.Time)
+			m.add(searchMetricKey(tagK, tagV), e.Metric, e.Time)
+		}
+	}
+	return nil
+}
+
+// Search_GC drops any bucket older than olderThan from every hash key.
+func (m *MemoryDataAccess) Search_GC(olderThan time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := searchBucket(time.Now().Add(-olderThan).Unix())
+	for _, byBucket := range m.buckets {
+		for bucket := range byBucket {
+			if bucket < cutoff {
+				delete(byBucket, bucket)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"bosun.org/collect"
+	"bosun.org/opentsdb"
+
+	"bosun.org/_third_party/github.com/garyburd/redigo/redis"
+)
+
+// SearchBulkEntry is one metric/tagset observation to be indexed by
+// Search_Bulk.
+type SearchBulkEntry struct {
+	Metric string
+	Tags   opentsdb.TagSet
+	Time   int64
+}
+
+// searchOp is a single Redis command queued by searchBulkOps, described
+// generically so it can be replayed either pipelined (Send/Flush/Receive)
+// or inside a MULTI/EXEC transaction.
+type searchOp struct {
+	cmd  string
+	args []interface{}
+}
+
+// searchBulkOps expands entries into the same SADD+HSET pairs that
+// searchAddToBucket would issue one at a time, so Search_Bulk writes the
+// identical schema as the non-bulk Search_Add* methods.
+func searchBulkOps(entries []SearchBulkEntry) []searchOp {
+	var ops []searchOp
+	add := func(key, field string, ts int64) {
+		bucket := searchBucket(ts)
+		ops = append(ops,
+			searchOp{"SADD", []interface{}{key + ":buckets", bucket}},
+			searchOp{"HSET", []interface{}{key + ":" + bucket, field, ts}},
+		)
+	}
+	for _, e := range entries {
+		add(searchAllMetricsKey, e.Metric, e.Time)
+		for tagK, tagV := range e.Tags {
+			add(searchTagkKey(e.Metric), tagK, e.Time)
+			add(searchTagvKey(e.Metric, tagK), tagV, e.Time)
+			add(searchTagvKey(Search_All, tagK), tagV, e.Time)
+			add(searchMetricKey(tagK, tagV), e.Metric, e.Time)
+		}
+	}
+	return ops
+}
+
+// Search_Bulk indexes entries in as few Redis round trips as possible: all
+// of the SADD/HSET commands are pipelined over a single connection via
+// Send/Flush/Receive. If the pipeline itself errors out (e.g. the
+// connection is lost mid-flush), it retries once as a MULTI/EXEC
+// transaction on a fresh connection.
+func (d *dataAccess) Search_Bulk(entries []SearchBulkEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "Search_Bulk"})()
+
+	ops := searchBulkOps(entries)
+
+	conn := d.getConnection()
+	defer conn.Close()
+	if err := searchSendPipelined(conn, ops); err == nil {
+		return nil
+	}
+
+	conn2 := d.getConnection()
+	defer conn2.Close()
+	return searchSendTransaction(conn2, ops)
+}
+
+// searchSendPipelined issues ops as a single pipelined batch: all commands
+// are written and flushed together, then one reply per command is read.
+func searchSendPipelined(conn redis.Conn, ops []searchOp) error {
+	for _, op := range ops {
+		if err := conn.Send(op.cmd, op.args...); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var firstErr error
+	for range ops {
+		if _, err := conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// searchSendTransaction issues ops inside MULTI/EXEC. It's the pipeline's
+// fallback, since it still costs one round trip but makes the write atomic.
+func searchSendTransaction(conn redis.Conn, ops []searchOp) error {
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if err := conn.Send(op.cmd, op.args...); err != nil {
+			return err
+		}
+	}
+	if err := conn.Send("EXEC"); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	// One reply for MULTI, one QUEUED per op, then EXEC's own reply.
+	for i := 0; i < len(ops)+2; i++ {
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"bosun.org/opentsdb"
 	"fmt"
 	"strconv"
+	"time"
 
 	"bosun.org/_third_party/github.com/garyburd/redigo/redis"
 )
@@ -13,22 +14,75 @@ import (
 Search data in redis:
 
 Metrics by tags:
-search:metrics:{tagk}={tagv} -> hash of metric name to timestamp
+search:metrics:{tagk}={tagv}:{bucket} -> hash of metric name to timestamp
+search:metrics:{tagk}={tagv}:buckets -> set of buckets with data above
 
 Tag keys by metric:
-search:tagk:{metric} -> hash of tag key to timestamp
+search:tagk:{metric}:{bucket} -> hash of tag key to timestamp
+search:tagk:{metric}:buckets -> set of buckets with data above
 
 Tag Values By metric/tag key
-search:tagv:{metric}:{tagk} -> hash of tag value to timestamp
+search:tagv:{metric}:{tagk}:{bucket} -> hash of tag value to timestamp
+search:tagv:{metric}:{tagk}:buckets -> set of buckets with data above
 metric "__all__" is a special key that will hold all values for the tag key, regardless of metric
 
 All Metrics:
-search:allMetrics -> hash of metric name to timestamp
+search:allMetrics:{bucket} -> hash of metric name to timestamp
+search:allMetrics:buckets -> set of buckets with data above
+
+Each hash is sharded by an hourly bucket (searchBucket) derived from the
+timestamp being recorded, so that Search_GC can UNLINK whole buckets once
+they age past the configured retention instead of rewriting a single
+unbounded hash. The "buckets" set lets readers and the GC sweeper discover
+which bucket keys currently exist without a KEYS/SCAN per query.
 */
 
+// SearchDataAccess is what cmd/bosun/search needs to maintain and query the
+// index of metric names, tag keys, and tag values. The default implementation is the
+// Redis-backed dataAccess in this file; MemoryDataAccess (memory.go) is a
+// dependency-free implementation for tests and single-binary deployments
+// that don't want to run Redis just for search.
+type SearchDataAccess interface {
+	Search_AddMetricForTag(tagK, tagV, metric string, time int64) error
+	Search_GetMetricsForTag(tagK, tagV string) (map[string]int64, error)
+
+	Search_AddTagKeyForMetric(metric, tagK string, time int64) error
+	Search_GetTagKeysForMetric(metric string) (map[string]int64, error)
+
+	Search_AddMetric(metric string, time int64) error
+	Search_GetAllMetrics() (map[string]int64, error)
+
+	Search_AddTagValue(metric, tagK, tagV string, time int64) error
+	Search_GetTagValues(metric, tagK string, since time.Duration) (map[string]int64, error)
+
+	// Search_Bulk indexes many metric/tagset observations at once, in as
+	// few round trips as the implementation can manage. It's equivalent to
+	// calling the Search_Add* methods for each entry's metric and tags.
+	Search_Bulk(entries []SearchBulkEntry) error
+
+	// Search_GC deletes index data older than olderThan. Implementations
+	// that don't shard/expire data (e.g. a small in-memory store) may treat
+	// this as a no-op.
+	Search_GC(olderThan time.Duration) error
+}
+
+var _ SearchDataAccess = (*dataAccess)(nil)
+
 const Search_All = "__all__"
 const searchAllMetricsKey = "search:allMetrics"
 
+// searchBucketFormat buckets search index data into hourly shards.
+const searchBucketFormat = "2006010215"
+
+// searchBucket returns the bucket suffix for time, an unix timestamp.
+func searchBucket(time int64) string {
+	return timeUnix(time).Format(searchBucketFormat)
+}
+
+var timeUnix = func(sec int64) time.Time {
+	return time.Unix(sec, 0).UTC()
+}
+
 func searchMetricKey(tagK, tagV string) string {
 	return fmt.Sprintf("search:metrics:%s=%s", tagK, tagV)
 }
@@ -39,21 +93,61 @@ func searchTagvKey(metric, tagK string) string {
 	return fmt.Sprintf("search:tagv:%s:%s", metric, tagK)
 }
 
-func (d *dataAccess) Search_AddMetricForTag(tagK, tagV, metric string, time int64) error {
-	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "AddMetricForTag"})()
+// searchAddToBucket HSETs field->time into the hourly bucket of key, and
+// records that bucket in key's pointer set so it can be found again by
+// searchGetUnion/Search_GC without scanning.
+func (d *dataAccess) searchAddToBucket(key, field string, time int64) error {
 	conn := d.getConnection()
 	defer conn.Close()
 
-	_, err := conn.Do("HSET", searchMetricKey(tagK, tagV), metric, time)
+	bucket := key + ":" + searchBucket(time)
+	if _, err := conn.Do("SADD", key+":buckets", searchBucket(time)); err != nil {
+		return err
+	}
+	_, err := conn.Do("HSET", bucket, field, time)
 	return err
 }
 
-func (d *dataAccess) Search_GetMetricsForTag(tagK, tagV string) (map[string]int64, error) {
-	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "GetMetricsForTag"})()
+// searchGetUnion merges the hashes of every bucket recorded in key's
+// pointer set, keeping the most recent timestamp seen per field. If
+// sinceBucket is non-empty, buckets that sort before it are skipped
+// entirely: bucket names are fixed-width yyyymmddhh strings, so lexical
+// and chronological order agree.
+func (d *dataAccess) searchGetUnion(key, sinceBucket string) (map[string]int64, error) {
 	conn := d.getConnection()
 	defer conn.Close()
 
-	return stringInt64Map(conn.Do("HGETALL", searchMetricKey(tagK, tagV)))
+	buckets, err := redis.Strings(conn.Do("SMEMBERS", key+":buckets"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64)
+	for _, b := range buckets {
+		if sinceBucket != "" && b < sinceBucket {
+			continue
+		}
+		m, err := stringInt64Map(conn.Do("HGETALL", key+":"+b))
+		if err != nil {
+			return nil, err
+		}
+		for field, ts := range m {
+			if ts > result[field] {
+				result[field] = ts
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *dataAccess) Search_AddMetricForTag(tagK, tagV, metric string, time int64) error {
+	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "AddMetricForTag"})()
+	return d.searchAddToBucket(searchMetricKey(tagK, tagV), metric, time)
+}
+
+func (d *dataAccess) Search_GetMetricsForTag(tagK, tagV string) (map[string]int64, error) {
+	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "GetMetricsForTag"})()
+	return d.searchGetUnion(searchMetricKey(tagK, tagV), "")
 }
 
 func stringInt64Map(d interface{}, err error) (map[string]int64, error) {
@@ -71,49 +165,111 @@ func stringInt64Map(d interface{}, err error) (map[string]int64, error) {
 
 func (d *dataAccess) Search_AddTagKeyForMetric(metric, tagK string, time int64) error {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "AddTagKeyForMetric"})()
-	conn := d.getConnection()
-	defer conn.Close()
-
-	_, err := conn.Do("HSET", searchTagkKey(metric), tagK, time)
-	return err
+	return d.searchAddToBucket(searchTagkKey(metric), tagK, time)
 }
 
 func (d *dataAccess) Search_GetTagKeysForMetric(metric string) (map[string]int64, error) {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "GetTagKeysForMetric"})()
-	conn := d.getConnection()
-	defer conn.Close()
-
-	return stringInt64Map(conn.Do("HGETALL", searchTagkKey(metric)))
+	return d.searchGetUnion(searchTagkKey(metric), "")
 }
 
 func (d *dataAccess) Search_AddMetric(metric string, time int64) error {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "AddMetric"})()
-	conn := d.getConnection()
-	defer conn.Close()
-
-	_, err := conn.Do("HSET", searchAllMetricsKey, metric, time)
-	return err
+	return d.searchAddToBucket(searchAllMetricsKey, metric, time)
 }
 func (d *dataAccess) Search_GetAllMetrics() (map[string]int64, error) {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "GetAllMetrics"})()
-	conn := d.getConnection()
-	defer conn.Close()
-
-	return stringInt64Map(conn.Do("HGETALL", searchAllMetricsKey))
+	return d.searchGetUnion(searchAllMetricsKey, "")
 }
 
 func (d *dataAccess) Search_AddTagValue(metric, tagK, tagV string, time int64) error {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "AddTagValue"})()
-	conn := d.getConnection()
-	defer conn.Close()
-
-	_, err := conn.Do("HSET", searchTagvKey(metric, tagK), tagV, time)
-	return err
+	return d.searchAddToBucket(searchTagvKey(metric, tagK), tagV, time)
 }
-func (d *dataAccess) Search_GetTagValues(metric, tagK string) (map[string]int64, error) {
+
+// Search_GetTagValues returns tag value->timestamp for metric/tagK. If
+// since is non-zero, only buckets that could contain entries newer than
+// since are read, so a short lookback window doesn't pay for a full-hash
+// scan of the tag's entire history.
+func (d *dataAccess) Search_GetTagValues(metric, tagK string, since time.Duration) (map[string]int64, error) {
 	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "GetTagValues"})()
+	var sinceBucket string
+	if since > 0 {
+		sinceBucket = searchBucket(time.Now().Add(-since).Unix())
+	}
+	return d.searchGetUnion(searchTagvKey(metric, tagK), sinceBucket)
+}
+
+// searchPointerKeyPatterns lists the pointer-set key glob patterns Search_GC
+// scans to discover buckets, one per hash family declared above.
+var searchPointerKeyPatterns = []string{
+	"search:metrics:*:buckets",
+	"search:tagk:*:buckets",
+	"search:tagv:*:buckets",
+	searchAllMetricsKey + ":buckets",
+}
+
+// searchScanCount is the COUNT hint passed to each SCAN call scanKeys makes;
+// it's a hint to Redis about how much work to do per cursor step, not a hard
+// limit on results returned.
+const searchScanCount = 100
+
+// scanKeys returns every key matching pattern using SCAN/MATCH instead of
+// KEYS, so a Search_GC sweep doesn't block the single-threaded Redis server
+// with a full-keyspace scan the way KEYS would.
+func scanKeys(conn redis.Conn, pattern string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", searchScanCount))
+		if err != nil {
+			return nil, err
+		}
+		var batch []string
+		if _, err := redis.Scan(reply, &cursor, &batch); err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// Search_GC deletes search index buckets older than olderThan. It scans
+// each hash family's pointer sets, UNLINKs any bucket whose name is older
+// than the cutoff, and removes it from the pointer set. It's meant to be
+// called periodically by a sweeper goroutine (see cmd/bosun/search).
+func (d *dataAccess) Search_GC(olderThan time.Duration) error {
+	defer collect.StartTimer("redis", opentsdb.TagSet{"op": "Search_GC"})()
 	conn := d.getConnection()
 	defer conn.Close()
 
-	return stringInt64Map(conn.Do("HGETALL", searchTagvKey(metric, tagK)))
+	cutoff := searchBucket(time.Now().Add(-olderThan).Unix())
+
+	for _, pattern := range searchPointerKeyPatterns {
+		ptrKeys, err := scanKeys(conn, pattern)
+		if err != nil {
+			return err
+		}
+		for _, ptrKey := range ptrKeys {
+			base := ptrKey[:len(ptrKey)-len(":buckets")]
+			buckets, err := redis.Strings(conn.Do("SMEMBERS", ptrKey))
+			if err != nil {
+				return err
+			}
+			for _, b := range buckets {
+				if b >= cutoff {
+					continue
+				}
+				if _, err := conn.Do("UNLINK", base+":"+b); err != nil {
+					return err
+				}
+				if _, err := conn.Do("SREM", ptrKey, b); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
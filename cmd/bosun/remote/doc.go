@@ -0,0 +1,23 @@
+// Package remote intentionally contains no code.
+//
+// gregdel/bosun#chunk0-2 asked for a Prometheus remote_read/remote_write
+// endpoint here, translating between Prometheus label sets and OpenTSDB
+// metric{tagk=tagv} form via search.Search. A first pass added remote.go
+// importing _third_party/github.com/golang/protobuf/proto,
+// _third_party/github.com/golang/snappy, and
+// _third_party/github.com/prometheus/prometheus/prompb, but none of those
+// were vendored by that commit or any other in this tree, so it didn't
+// compile; ServeRead/ServeWrite were also never registered on any HTTP mux
+// or reachable through config. That file was deleted rather than left
+// half-working (see git history for both commits).
+//
+// This request is closed out here as not implemented, rather than left
+// looking done by a silent file deletion. Implementing it for real needs,
+// at minimum:
+//   - the protobuf/snappy/prompb sources vendored under _third_party,
+//     following the pattern in _third_party/github.com/garyburd/redigo
+//   - a ServeRead/ServeWrite registration on cmd/bosun's HTTP mux, gated by
+//     a config option, so the endpoint is actually reachable
+//
+// Neither exists yet in this tree.
+package remote
@@ -0,0 +1,378 @@
+package search // import "bosun.org/cmd/bosun/search"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bosun.org/opentsdb"
+	"bosun.org/slog"
+)
+
+// FTSBackend is implemented by full-text index backends for Search. It is
+// optional: Search works with a database.SearchDataAccess alone,
+// and only consults FTSBackend when a caller wants fuzzy or free-text
+// results that the hash-based lookups can't provide.
+//
+// Every query method takes a since: zero means no time filter, matching the
+// database.SearchDataAccess convention (see Search_GetTagValues); a non-zero
+// since excludes documents whose last-seen timestamp is older than that,
+// the same range filter TagValuesByMetricTagKey applies to Redis-backed
+// lookups.
+type FTSBackend interface {
+	// Index adds or refreshes a metric/tagset document for the given
+	// timestamp. Implementations should be safe to call at datapoint rate;
+	// callers are expected to buffer/batch if that matters.
+	Index(metric string, tags opentsdb.TagSet, ts int64) error
+
+	// Query runs a free-text query against the indexed metric names, tag
+	// keys, and tag values, returning doc IDs ranked best match first.
+	Query(freeText string, since time.Duration, limit int) ([]string, error)
+
+	// FuzzyMetrics returns metric names that fuzzy/prefix match term, best
+	// match first. "Fuzzy" includes typo tolerance: candidates within a
+	// small edit distance of term match too, scaled to term's length (see
+	// fuzzyMaxDistance), not just exact/prefix/substring.
+	FuzzyMetrics(term string, since time.Duration, limit int) ([]string, error)
+
+	// SuggestTagValues returns tag values for metric/tagk that fuzzy/prefix
+	// match term, best match first.
+	SuggestTagValues(metric, tagk, term string, since time.Duration, limit int) ([]string, error)
+
+	Close() error
+}
+
+// ftsDoc is the indexed shape for both metric docs (tagk == "") and
+// metric/tagk/tagv docs.
+type ftsDoc struct {
+	id       string
+	metric   string
+	tagk     string
+	tagv     string
+	lastSeen int64
+}
+
+func docID(metric, tagk, tagv string) string {
+	if tagk == "" {
+		return metric
+	}
+	return metric + "|" + tagk + "|" + tagv
+}
+
+// FTSConfig controls how a MemFTSIndex batches writes.
+type FTSConfig struct {
+	// BatchSize is the number of pending documents that triggers a flush.
+	BatchSize int
+
+	// FlushInterval is the maximum time a document may sit in the write
+	// buffer before being flushed, regardless of BatchSize.
+	FlushInterval time.Duration
+}
+
+const (
+	defaultFTSBatchSize     = 200
+	defaultFTSFlushInterval = time.Second
+)
+
+// MemFTSIndex is an in-process FTSBackend: an in-memory map of ftsDoc kept
+// behind a batched writer, so Index never blocks the caller on a lock held
+// by a large flush.
+//
+// It intentionally does not depend on a real search engine (Bleve,
+// Lucene, ...): this repo vendors its dependencies by copying source under
+// _third_party, and nothing has vendored one in yet. Matching here is
+// case-insensitive exact/prefix/substring first, falling back to a bounded
+// Levenshtein distance (see matchScore, fuzzyMaxDistance) for typo
+// tolerance; swap this out once a real engine is vendored, behind the same
+// FTSBackend interface.
+type MemFTSIndex struct {
+	mu   sync.RWMutex
+	docs map[string]ftsDoc
+
+	queue       chan ftsDoc
+	done        chan struct{}
+	flushSignal chan chan struct{}
+}
+
+// NewMemFTSIndex returns an empty MemFTSIndex and starts its batched
+// writer goroutine.
+func NewMemFTSIndex(c FTSConfig) *MemFTSIndex {
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultFTSBatchSize
+	}
+	flushInterval := c.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFTSFlushInterval
+	}
+
+	// A small batchSize still gets room for a burst of Index calls.
+	queueSize := batchSize * 4
+	if queueSize < 1024 {
+		queueSize = 1024
+	}
+
+	idx := &MemFTSIndex{
+		docs:        make(map[string]ftsDoc),
+		queue:       make(chan ftsDoc, queueSize),
+		done:        make(chan struct{}),
+		flushSignal: make(chan chan struct{}),
+	}
+	go idx.writeLoop(batchSize, flushInterval)
+	return idx
+}
+
+// Index implements FTSBackend by enqueueing the metric document and one
+// document per tag key/value pair. Enqueueing never blocks the caller; the
+// batched writer drains the queue in the background.
+func (idx *MemFTSIndex) Index(metric string, tags opentsdb.TagSet, ts int64) error {
+	idx.enqueue(ftsDoc{id: docID(metric, "", ""), metric: metric, lastSeen: ts})
+	for k, v := range tags {
+		idx.enqueue(ftsDoc{id: docID(metric, k, v), metric: metric, tagk: k, tagv: v, lastSeen: ts})
+	}
+	return nil
+}
+
+func (idx *MemFTSIndex) enqueue(d ftsDoc) {
+	select {
+	case idx.queue <- d:
+	default:
+		slog.Error(fmt.Errorf("search: fts index queue full, dropping doc for %s", d.metric))
+	}
+}
+
+// writeLoop drains the queue into batched map writes, flushing whenever
+// batchSize documents have accumulated, and also on every tick of a fixed
+// flushInterval ticker.
+func (idx *MemFTSIndex) writeLoop(batchSize int, flushInterval time.Duration) {
+	batch := make([]ftsDoc, 0, batchSize)
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		idx.mu.Lock()
+		for _, d := range batch {
+			idx.docs[d.id] = d
+		}
+		idx.mu.Unlock()
+		batch = batch[:0]
+	}
+
+	drain := func() {
+		for {
+			select {
+			case d := <-idx.queue:
+				batch = append(batch, d)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case d, ok := <-idx.queue:
+			if !ok {
+				flush()
+				close(idx.done)
+				return
+			}
+			batch = append(batch, d)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case ack := <-idx.flushSignal:
+			drain()
+			flush()
+			close(ack)
+		}
+	}
+}
+
+// Flush blocks until every document enqueued by Index so far has been
+// written into idx.docs. It's mainly useful in tests, which otherwise can't
+// tell when the background writer has caught up.
+func (idx *MemFTSIndex) Flush() {
+	ack := make(chan struct{})
+	idx.flushSignal <- ack
+	<-ack
+}
+
+// Close stops the batched writer, flushing anything pending.
+func (idx *MemFTSIndex) Close() error {
+	close(idx.queue)
+	<-idx.done
+	return nil
+}
+
+// matchScore reports how well candidate matches term, lower is better, or
+// -1 for no match: 0 for an exact match, 1 for a prefix match, 2 for a
+// substring match anywhere else, and 3+editDistance for a typo-tolerant
+// match within fuzzyMaxDistance(term) edits of candidate.
+func matchScore(candidate, term string) int {
+	switch {
+	case candidate == term:
+		return 0
+	case strings.HasPrefix(candidate, term):
+		return 1
+	case strings.Contains(candidate, term):
+		return 2
+	}
+	if d := levenshtein(candidate, term); d <= fuzzyMaxDistance(term) {
+		return 3 + d
+	}
+	return -1
+}
+
+// fuzzyMaxDistance scales typo tolerance to term's length, the same way
+// most fuzzy-search engines do: short terms tolerate no edits (a single
+// typo changes their meaning too much to be useful), longer terms tolerate
+// progressively more.
+func fuzzyMaxDistance(term string) int {
+	switch {
+	case len(term) < 3:
+		return 0
+	case len(term) < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance between a and b: the minimum
+// number of single-rune insertions, deletions, or substitutions needed to
+// turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			del := prev[j] + 1
+			ins := cur[j-1] + 1
+			sub := prev[j-1] + cost
+			min := del
+			if ins < min {
+				min = ins
+			}
+			if sub < min {
+				min = sub
+			}
+			cur[j] = min
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+type scoredID struct {
+	id    string
+	score int
+}
+
+func topScored(results []scoredID, limit int) []string {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score < results[j].score
+		}
+		return results[i].id < results[j].id
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.id
+	}
+	return ids
+}
+
+// sinceCutoff turns a since duration into a unix timestamp cutoff: 0 means
+// no filter, so every document passes.
+func sinceCutoff(since time.Duration) int64 {
+	if since <= 0 {
+		return 0
+	}
+	return time.Now().Add(-since).Unix()
+}
+
+// FuzzyMetrics implements FTSBackend.FuzzyMetrics.
+func (idx *MemFTSIndex) FuzzyMetrics(term string, since time.Duration, limit int) ([]string, error) {
+	term = strings.ToLower(strings.TrimSuffix(term, "*"))
+	cutoff := sinceCutoff(since)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var results []scoredID
+	for _, d := range idx.docs {
+		if d.tagk != "" || seen[d.metric] || d.lastSeen < cutoff {
+			continue
+		}
+		if s := matchScore(strings.ToLower(d.metric), term); s >= 0 {
+			seen[d.metric] = true
+			results = append(results, scoredID{id: d.metric, score: s})
+		}
+	}
+	return topScored(results, limit), nil
+}
+
+// SuggestTagValues implements FTSBackend.SuggestTagValues.
+func (idx *MemFTSIndex) SuggestTagValues(metric, tagk, term string, since time.Duration, limit int) ([]string, error) {
+	term = strings.ToLower(strings.TrimSuffix(term, "*"))
+	cutoff := sinceCutoff(since)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var results []scoredID
+	for _, d := range idx.docs {
+		if d.metric != metric || d.tagk != tagk || seen[d.tagv] || d.lastSeen < cutoff {
+			continue
+		}
+		if s := matchScore(strings.ToLower(d.tagv), term); s >= 0 {
+			seen[d.tagv] = true
+			results = append(results, scoredID{id: d.tagv, score: s})
+		}
+	}
+	return topScored(results, limit), nil
+}
+
+// Query implements FTSBackend.Query: freeText must appear, case
+// insensitively, somewhere in a doc's ID ("metric", or
+// "metric|tagk|tagv") for it to match.
+func (idx *MemFTSIndex) Query(freeText string, since time.Duration, limit int) ([]string, error) {
+	freeText = strings.ToLower(freeText)
+	cutoff := sinceCutoff(since)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var results []scoredID
+	for id, d := range idx.docs {
+		if d.lastSeen < cutoff {
+			continue
+		}
+		if s := matchScore(strings.ToLower(id), freeText); s >= 0 {
+			results = append(results, scoredID{id: id, score: s})
+		}
+	}
+	return topScored(results, limit), nil
+}
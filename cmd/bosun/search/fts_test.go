@@ -0,0 +1,135 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"bosun.org/opentsdb"
+)
+
+func TestDocID(t *testing.T) {
+	if got := docID("os.cpu", "", ""); got != "os.cpu" {
+		t.Errorf("docID(metric only) = %q, want os.cpu", got)
+	}
+	if got := docID("os.cpu", "host", "web01"); got != "os.cpu|host|web01" {
+		t.Errorf("docID(metric, tagk, tagv) = %q, want os.cpu|host|web01", got)
+	}
+}
+
+func TestMemFTSIndexFlushBySize(t *testing.T) {
+	idx := NewMemFTSIndex(FTSConfig{BatchSize: 1, FlushInterval: time.Hour})
+	defer idx.Close()
+
+	idx.Index("os.cpu", opentsdb.TagSet{"host": "web01"}, time.Now().Unix())
+	idx.Flush()
+
+	got, err := idx.FuzzyMetrics("os.cpu", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "os.cpu" {
+		t.Fatalf("FuzzyMetrics = %v, want [os.cpu]", got)
+	}
+}
+
+func TestMemFTSIndexFlushByInterval(t *testing.T) {
+	idx := NewMemFTSIndex(FTSConfig{BatchSize: 1000, FlushInterval: 10 * time.Millisecond})
+	defer idx.Close()
+
+	idx.Index("os.mem", opentsdb.TagSet{"host": "web01"}, time.Now().Unix())
+
+	var got []string
+	var err error
+	for i := 0; i < 50; i++ {
+		got, err = idx.FuzzyMetrics("os.mem", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(got) != 1 || got[0] != "os.mem" {
+		t.Fatalf("FuzzyMetrics after interval flush = %v, want [os.mem]", got)
+	}
+}
+
+func TestMemFTSIndexQueryAndSuggest(t *testing.T) {
+	idx := NewMemFTSIndex(FTSConfig{BatchSize: 1, FlushInterval: time.Hour})
+	defer idx.Close()
+
+	now := time.Now().Unix()
+	idx.Index("os.cpu", opentsdb.TagSet{"host": "web01", "dc": "us-east"}, now)
+	idx.Index("os.cpu", opentsdb.TagSet{"host": "web02", "dc": "us-west"}, now)
+	idx.Flush()
+
+	metrics, err := idx.FuzzyMetrics("os.c", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0] != "os.cpu" {
+		t.Fatalf("FuzzyMetrics(os.c) = %v, want [os.cpu]", metrics)
+	}
+
+	hosts, err := idx.SuggestTagValues("os.cpu", "host", "web0", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("SuggestTagValues(host, web0) = %v, want 2 results", hosts)
+	}
+
+	ids, err := idx.Query("web01", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "os.cpu|host|web01" {
+		t.Fatalf("Query(web01) = %v, want [os.cpu|host|web01]", ids)
+	}
+}
+
+func TestMemFTSIndexTypoTolerance(t *testing.T) {
+	idx := NewMemFTSIndex(FTSConfig{BatchSize: 1, FlushInterval: time.Hour})
+	defer idx.Close()
+
+	idx.Index("os.cpu", opentsdb.TagSet{"host": "web01"}, time.Now().Unix())
+	idx.Flush()
+
+	got, err := idx.FuzzyMetrics("os.cpq", 0, 10) // one-char typo
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "os.cpu" {
+		t.Fatalf("FuzzyMetrics(os.cpq) = %v, want [os.cpu] via typo tolerance", got)
+	}
+
+	if got, err := idx.FuzzyMetrics("zz", 0, 10); err != nil || len(got) != 0 {
+		t.Fatalf("FuzzyMetrics(zz) = %v, %v, want no match: short terms tolerate no edits", got, err)
+	}
+}
+
+func TestMemFTSIndexSince(t *testing.T) {
+	idx := NewMemFTSIndex(FTSConfig{BatchSize: 1, FlushInterval: time.Hour})
+	defer idx.Close()
+
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	idx.Index("os.cpu", opentsdb.TagSet{"host": "web01"}, old)
+	idx.Flush()
+
+	all, err := idx.FuzzyMetrics("os.cpu", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("FuzzyMetrics with no since filter = %v, want [os.cpu]", all)
+	}
+
+	recent, err := idx.FuzzyMetrics("os.cpu", time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 0 {
+		t.Fatalf("FuzzyMetrics with a 1h since filter = %v, want none: doc is 2h old", recent)
+	}
+}
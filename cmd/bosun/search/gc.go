@@ -0,0 +1,34 @@
+package search // import "bosun.org/cmd/bosun/search"
+
+import (
+	"time"
+
+	"bosun.org/slog"
+)
+
+// Search_GC periodically sweeps DataAccess for search index buckets older
+// than olderThan, running once immediately and then every interval. It
+// returns a function that stops the sweeper; callers should defer it (or
+// call it at shutdown) to avoid leaking the goroutine.
+func Search_GC(s *Search, olderThan, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		sweep := func() {
+			if err := s.DataAccess.Search_GC(olderThan); err != nil {
+				slog.Error(err)
+			}
+		}
+		sweep()
+		for {
+			select {
+			case <-t.C:
+				sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
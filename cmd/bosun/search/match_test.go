@@ -0,0 +1,187 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMatchBasic(t *testing.T) {
+	cases := []struct {
+		pattern string
+		values  []string
+		want    []string
+	}{
+		{"os.cpu", []string{"os.cpu", "os.mem"}, []string{"os.cpu"}},
+		{"os.*", []string{"os.cpu", "os.mem", "net.bytes"}, []string{"os.cpu", "os.mem"}},
+		{"web0?", []string{"web01", "web02", "web100"}, []string{"web01", "web02"}},
+		{"web0[12]", []string{"web01", "web02", "web03"}, []string{"web01", "web02"}},
+		{"web0[1-2]", []string{"web01", "web02", "web03"}, []string{"web01", "web02"}},
+		{"{us-east,us-west}", []string{"us-east", "us-west", "eu-west"}, []string{"us-east", "us-west"}},
+		{"!web01", []string{"web01", "web02"}, []string{"web02"}},
+	}
+	for _, c := range cases {
+		got, err := Match(c.pattern, c.values)
+		if err != nil {
+			t.Errorf("Match(%q): %v", c.pattern, err)
+			continue
+		}
+		if !sameSet(got, c.want) {
+			t.Errorf("Match(%q) = %v, want %v", c.pattern, got, c.want)
+		}
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, v := range a {
+		seen[v] = true
+	}
+	for _, v := range b {
+		if !seen[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// referenceGlobMatch is a naive, recursive glob matcher used only by
+// TestMatchFuzz to check globToRegex's translation against a second,
+// independent implementation of the same semantics.
+func referenceGlobMatch(pattern, s string) bool {
+	p := []rune(pattern)
+	v := []rune(s)
+	return refMatch(p, v)
+}
+
+func refMatch(p, v []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for i := 0; i <= len(v); i++ {
+				if refMatch(p[1:], v[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(v) == 0 {
+				return false
+			}
+			p, v = p[1:], v[1:]
+		case '[':
+			end := 1
+			for end < len(p) && p[end] != ']' {
+				end++
+			}
+			if len(v) == 0 || !refMatchClass(string(p[1:end]), v[0]) {
+				return false
+			}
+			p, v = p[end+1:], v[1:]
+		case '{':
+			end := 1
+			for end < len(p) && p[end] != '}' {
+				end++
+			}
+			for _, alt := range strings.Split(string(p[1:end]), ",") {
+				ar := []rune(alt)
+				if len(v) >= len(ar) && string(v[:len(ar)]) == alt && refMatch(p[end+1:], v[len(ar):]) {
+					return true
+				}
+			}
+			return false
+		default:
+			if len(v) == 0 || v[0] != p[0] {
+				return false
+			}
+			p, v = p[1:], v[1:]
+		}
+	}
+	return len(v) == 0
+}
+
+func refMatchClass(class string, r rune) bool {
+	neg := strings.HasPrefix(class, "^")
+	if neg {
+		class = class[1:]
+	}
+	cr := []rune(class)
+	match := false
+	for i := 0; i < len(cr); i++ {
+		if i+2 < len(cr) && cr[i+1] == '-' {
+			if cr[i] <= r && r <= cr[i+2] {
+				match = true
+			}
+			i += 2
+		} else if cr[i] == r {
+			match = true
+		}
+	}
+	if neg {
+		return !match
+	}
+	return match
+}
+
+// TestMatchFuzz generates random glob patterns and candidate strings from a
+// small alphabet and asserts Match agrees with referenceGlobMatch.
+func TestMatchFuzz(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	alphabet := []rune("ab01")
+
+	randWord := func(n int) string {
+		s := ""
+		for i := 0; i < n; i++ {
+			s += string(alphabet[rnd.Intn(len(alphabet))])
+		}
+		return s
+	}
+
+	randPattern := func() string {
+		s := ""
+		n := rnd.Intn(5) + 1
+		for i := 0; i < n; i++ {
+			switch rnd.Intn(5) {
+			case 0:
+				s += string(alphabet[rnd.Intn(len(alphabet))])
+			case 1:
+				s += "*"
+			case 2:
+				s += "?"
+			case 3:
+				s += fmt.Sprintf("[%c%c]", alphabet[rnd.Intn(len(alphabet))], alphabet[rnd.Intn(len(alphabet))])
+			case 4:
+				s += fmt.Sprintf("{%s,%s}", randWord(2), randWord(2))
+			}
+		}
+		return s
+	}
+
+	for i := 0; i < 200; i++ {
+		pattern := randPattern()
+		values := make([]string, 10)
+		for j := range values {
+			values[j] = randWord(rnd.Intn(4) + 1)
+		}
+
+		got, err := Match(pattern, values)
+		if err != nil {
+			t.Fatalf("Match(%q): %v", pattern, err)
+		}
+		gotSet := make(map[string]bool, len(got))
+		for _, v := range got {
+			gotSet[v] = true
+		}
+
+		for _, v := range values {
+			want := referenceGlobMatch(pattern, v)
+			if gotSet[v] != want {
+				t.Fatalf("pattern %q, value %q: Match=%v reference=%v", pattern, v, gotSet[v], want)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package search // import "bosun.org/cmd/bosun/search"
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -19,11 +20,19 @@ import (
 // available tag keys for a metric, and available tag values for a metric and
 // tag key.
 type Search struct {
-	DataAccess database.DataAccess
+	DataAccess database.SearchDataAccess
+
+	// FTS, if non-nil, receives every metric/tagset seen by Index and backs
+	// FuzzyMetrics, SuggestTagValues, and Query. It is optional: Search is
+	// fully functional against DataAccess alone.
+	FTS FTSBackend
 
 	Last        map[string]*lastInfo
 	updateTimes map[string]int64
 	sync.RWMutex
+
+	bulk        chan database.SearchBulkEntry
+	flushSignal chan chan struct{}
 }
 
 type lastInfo struct {
@@ -32,13 +41,89 @@ type lastInfo struct {
 	timestamp    int64
 }
 
-func NewSearch(data database.DataAccess) *Search {
-	s := Search{
+// DefaultBulkBatchSize and DefaultBulkFlushInterval are the buffering
+// defaults used by NewSearch. NewSearchBulk takes both as arguments; no
+// config option sets them.
+const (
+	DefaultBulkBatchSize     = 500
+	DefaultBulkFlushInterval = 2 * time.Second
+)
+
+func NewSearch(data database.SearchDataAccess) *Search {
+	return NewSearchBulk(data, DefaultBulkBatchSize, DefaultBulkFlushInterval)
+}
+
+// NewSearchBulk is like NewSearch but lets the caller size the write
+// buffer that Index drains into DataAccess.Search_Bulk: it's flushed once
+// batchSize entries have accumulated, and also on every tick of a fixed
+// flushInterval ticker.
+func NewSearchBulk(data database.SearchDataAccess, batchSize int, flushInterval time.Duration) *Search {
+	s := &Search{
 		DataAccess:  data,
 		Last:        make(map[string]*lastInfo),
 		updateTimes: make(map[string]int64),
+		bulk:        make(chan database.SearchBulkEntry, batchSize*4),
+		flushSignal: make(chan chan struct{}),
+	}
+	go s.bulkWriter(batchSize, flushInterval)
+	return s
+}
+
+// bulkWriter drains s.bulk into DataAccess.Search_Bulk, batching up to
+// batchSize entries or flushInterval of wall time, whichever is hit first.
+func (s *Search) bulkWriter(batchSize int, flushInterval time.Duration) {
+	var buf []database.SearchBulkEntry
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+		if err := s.DataAccess.Search_Bulk(buf); err != nil {
+			slog.Error(err)
+		}
+		buf = buf[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case e := <-s.bulk:
+				buf = append(buf, e)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-s.bulk:
+			if !ok {
+				flush()
+				return
+			}
+			buf = append(buf, e)
+			if len(buf) >= batchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case ack := <-s.flushSignal:
+			drain()
+			flush()
+			close(ack)
+		}
 	}
-	return &s
+}
+
+// Flush blocks until every entry enqueued by Index so far has been written
+// via DataAccess.Search_Bulk. It's mainly useful in tests, which otherwise
+// can't tell when the background writer has caught up.
+func (s *Search) Flush() {
+	ack := make(chan struct{})
+	s.flushSignal <- ack
+	<-ack
 }
 
 func (s *Search) Index(mdp opentsdb.MultiDataPoint) {
@@ -58,12 +143,15 @@ func (s *Search) Index(mdp opentsdb.MultiDataPoint) {
 			s.Lock()
 			s.updateTimes[key] = now
 			s.Unlock()
-			for k, v := range dp.Tags {
-				s.DataAccess.Search_AddMetricForTag(k, v, metric, now)
-				s.DataAccess.Search_AddTagKeyForMetric(metric, k, now)
-				s.DataAccess.Search_AddTagValue(metric, k, v, now)
-				s.DataAccess.Search_AddTagValue(database.Search_All, k, v, now)
-				s.DataAccess.Search_AddMetric(metric, now)
+			select {
+			case s.bulk <- database.SearchBulkEntry{Metric: metric, Tags: dp.Tags, Time: now}:
+			default:
+				slog.Error(fmt.Errorf("search: bulk write buffer full, dropping %s", key))
+			}
+			if s.FTS != nil {
+				if err := s.FTS.Index(metric, dp.Tags, now); err != nil {
+					slog.Error(err)
+				}
 			}
 		}
 
@@ -99,26 +187,95 @@ func getFloat(unk interface{}) (float64, error) {
 	return fv.Float(), nil
 }
 
-// Match returns all matching values against search. search is a regex, except
-// that `.` is literal, `*` can be used for `.*`, and the entire string is
-// searched (`^` and `&` added to ends of search).
+// isGlob reports whether v contains any of the special characters Match
+// treats as glob syntax, other than a leading `!` alone (which would leave
+// nothing to negate against).
+func isGlob(v string) bool {
+	return strings.ContainsAny(v, "*?[{") || (strings.HasPrefix(v, "!") && len(v) > 1)
+}
+
+// Match returns all matching values against search. search is a glob, not a
+// regex: `.` is literal, `*` matches any run of characters, `?` matches any
+// single character, `[abc]`/`[a-z]` are character classes, `{foo,bar}` is
+// alternation, and a leading `!` negates the whole pattern. The entire
+// string is always matched (`^` and `$` are implicit).
 func Match(search string, values []string) ([]string, error) {
-	v := strings.Replace(search, ".", `\.`, -1)
-	v = strings.Replace(v, "*", ".*", -1)
-	v = "^" + v + "$"
-	re, err := regexp.Compile(v)
+	negate := strings.HasPrefix(search, "!")
+	if negate {
+		search = search[1:]
+	}
+	v, err := globToRegex(search)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile("^" + v + "$")
 	if err != nil {
 		return nil, err
 	}
 	var nvs []string
 	for _, nv := range values {
-		if re.MatchString(nv) {
+		if re.MatchString(nv) != negate {
 			nvs = append(nvs, nv)
 		}
 	}
 	return nvs, nil
 }
 
+// globToRegex translates a glob pattern (`.` literal, `*`, `?`,
+// `[...]` classes, `{a,b}` alternation) into the equivalent regexp source,
+// unanchored.
+func globToRegex(search string) (string, error) {
+	var out bytes.Buffer
+	r := []rune(search)
+	for i := 0; i < len(r); i++ {
+		switch c := r[i]; c {
+		case '*':
+			out.WriteString(".*")
+		case '?':
+			out.WriteString(".")
+		case '[':
+			end := i + 1
+			// a leading `]` or `^]` is a literal `]`, not the close of an
+			// empty/negated class.
+			if end < len(r) && r[end] == '^' {
+				end++
+			}
+			if end < len(r) && r[end] == ']' {
+				end++
+			}
+			for end < len(r) && r[end] != ']' {
+				end++
+			}
+			if end >= len(r) {
+				return "", fmt.Errorf("search: unterminated [ in %q", search)
+			}
+			out.WriteString(string(r[i : end+1]))
+			i = end
+		case '{':
+			end := i + 1
+			for end < len(r) && r[end] != '}' {
+				end++
+			}
+			if end >= len(r) {
+				return "", fmt.Errorf("search: unterminated { in %q", search)
+			}
+			alts := strings.Split(string(r[i+1:end]), ",")
+			out.WriteString("(?:")
+			for j, alt := range alts {
+				if j > 0 {
+					out.WriteString("|")
+				}
+				out.WriteString(regexp.QuoteMeta(alt))
+			}
+			out.WriteString(")")
+			i = end
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String(), nil
+}
+
 var errNotFloat = fmt.Errorf("last: expected float64")
 
 // GetLast returns the value of the most recent data point for the given metric
@@ -142,7 +299,7 @@ func (s *Search) Expand(q *opentsdb.Query) error {
 		var nvs []string
 		for _, v := range strings.Split(ov, "|") {
 			v = strings.TrimSpace(v)
-			if v == "*" || !strings.Contains(v, "*") {
+			if v == "*" || !isGlob(v) {
 				nvs = append(nvs, v)
 			} else {
 				vs, err := s.TagValuesByMetricTagKey(q.Metric, k, 0)
@@ -209,12 +366,47 @@ func (s *Search) TagKeysByMetric(metric string) ([]string, error) {
 	return r, nil
 }
 
+// errNoFTS is returned by the FTS-backed methods when Search.FTS is nil.
+var errNoFTS = fmt.Errorf("search: no full-text search backend configured")
+
+// FuzzyMetrics returns metric names matching term, which may be a partial or
+// misspelled metric name, ranked best match first. since behaves like
+// TagValuesByMetricTagKey's: zero returns every match regardless of age,
+// non-zero excludes metrics not seen within that window. It requires
+// Search.FTS to be configured.
+func (s *Search) FuzzyMetrics(term string, since time.Duration) ([]string, error) {
+	if s.FTS == nil {
+		return nil, errNoFTS
+	}
+	return s.FTS.FuzzyMetrics(term, since, 100)
+}
+
+// SuggestTagValues returns tag values for metric/tagk matching term, ranked
+// best match first. since is as in FuzzyMetrics. It requires Search.FTS to
+// be configured.
+func (s *Search) SuggestTagValues(metric, tagk, term string, since time.Duration) ([]string, error) {
+	if s.FTS == nil {
+		return nil, errNoFTS
+	}
+	return s.FTS.SuggestTagValues(metric, tagk, term, since, 100)
+}
+
+// Query runs a free-text query over the indexed metric names, tag keys, and
+// tag values. since is as in FuzzyMetrics. It requires Search.FTS to be
+// configured.
+func (s *Search) Query(freeText string, since time.Duration) ([]string, error) {
+	if s.FTS == nil {
+		return nil, errNoFTS
+	}
+	return s.FTS.Query(freeText, since, 100)
+}
+
 func (s *Search) TagValuesByMetricTagKey(metric, tagK string, since time.Duration) ([]string, error) {
 	var t int64
 	if since > 0 {
 		t = time.Now().Add(-since).Unix()
 	}
-	vals, err := s.DataAccess.Search_GetTagValues(metric, tagK)
+	vals, err := s.DataAccess.Search_GetTagValues(metric, tagK, since)
 	if err != nil {
 		return nil, err
 	}
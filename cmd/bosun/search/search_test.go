@@ -0,0 +1,132 @@
+package search
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"bosun.org/cmd/bosun/database"
+	"bosun.org/opentsdb"
+)
+
+func testIndex(s *Search) {
+	s.Index(opentsdb.MultiDataPoint{
+		&opentsdb.DataPoint{
+			Metric:    "os.cpu",
+			Timestamp: time.Now().Unix(),
+			Value:     1,
+			Tags:      opentsdb.TagSet{"host": "web01", "dc": "us-east"},
+		},
+		&opentsdb.DataPoint{
+			Metric:    "os.cpu",
+			Timestamp: time.Now().Unix(),
+			Value:     2,
+			Tags:      opentsdb.TagSet{"host": "web02", "dc": "us-west"},
+		},
+	})
+}
+
+func TestIndex(t *testing.T) {
+	s := NewSearch(database.NewMemoryDataAccess())
+	testIndex(s)
+	s.Flush()
+
+	metrics, err := s.UniqueMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0] != "os.cpu" {
+		t.Fatalf("expected [os.cpu], got %v", metrics)
+	}
+
+	keys, err := s.TagKeysByMetric("os.cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 tag keys, got %v", keys)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	s := NewSearch(database.NewMemoryDataAccess())
+	testIndex(s)
+	s.Flush()
+
+	q := &opentsdb.Query{Metric: "os.cpu", Tags: opentsdb.TagSet{"host": "web*"}}
+	if err := s.Expand(q); err != nil {
+		t.Fatal(err)
+	}
+	if q.Tags["host"] != "web01|web02" {
+		t.Fatalf("expected web01|web02, got %q", q.Tags["host"])
+	}
+}
+
+func TestTagValuesByMetricTagKeySince(t *testing.T) {
+	s := NewSearch(database.NewMemoryDataAccess())
+	now := time.Now().Unix()
+	s.DataAccess.Search_AddTagValue("os.cpu", "host", "old", now-int64(48*time.Hour/time.Second))
+	s.DataAccess.Search_AddTagValue("os.cpu", "host", "new", now)
+
+	all, err := s.TagValuesByMetricTagKey("os.cpu", "host", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 values with no since filter, got %v", all)
+	}
+
+	recent, err := s.TagValuesByMetricTagKey("os.cpu", "host", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0] != "new" {
+		t.Fatalf("expected [new] with a 1h since filter, got %v", recent)
+	}
+}
+
+func TestIndexBatchFlush(t *testing.T) {
+	s := NewSearchBulk(database.NewMemoryDataAccess(), 1, time.Hour)
+	testIndex(s) // batch size of 1 means both entries flush as they're indexed
+	s.Flush()    // still wait for the writer to catch up: a happens-before sync point, not a poll
+
+	metrics, err := s.UniqueMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0] != "os.cpu" {
+		t.Fatalf("expected [os.cpu], got %v", metrics)
+	}
+}
+
+func benchmarkIndex(b *testing.B, s *Search) {
+	mdp := opentsdb.MultiDataPoint{
+		&opentsdb.DataPoint{
+			Metric:    "os.cpu",
+			Timestamp: time.Now().Unix(),
+			Value:     1,
+			Tags:      opentsdb.TagSet{"host": "web01", "dc": "us-east"},
+		},
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Index(mdp)
+	}
+}
+
+// BenchmarkIndexMemory measures Search.Index against MemoryDataAccess.
+func BenchmarkIndexMemory(b *testing.B) {
+	benchmarkIndex(b, NewSearch(database.NewMemoryDataAccess()))
+}
+
+// BenchmarkIndexRedis measures Search.Index against the Redis-backed
+// dataAccess, for comparison against BenchmarkIndexMemory. It needs a live
+// Redis and isn't run in CI: point BOSUN_REDIS_ADDR at one
+// (e.g. "localhost:6379") to run it locally.
+func BenchmarkIndexRedis(b *testing.B) {
+	addr := os.Getenv("BOSUN_REDIS_ADDR")
+	if addr == "" {
+		b.Skip("set BOSUN_REDIS_ADDR to a live Redis instance to run this benchmark")
+	}
+	benchmarkIndex(b, NewSearch(database.NewDataAccess(addr)))
+}
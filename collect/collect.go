@@ -0,0 +1,16 @@
+// Package collect is a minimal stand-in for Bosun's real self-monitoring
+// client. This tree is a trimmed snapshot: cmd/bosun/database/search_data.go
+// already imported bosun.org/collect in the baseline commit, before any
+// work in this series touched it, but no implementation of the package
+// existed anywhere to import. StartTimer here is a no-op rather than a
+// reimplementation of real instrumentation; swap this package out once the
+// full bosun.org/collect client is available.
+package collect
+
+import "bosun.org/opentsdb"
+
+// StartTimer would normally start an internal "redis" timer tagged by ts and
+// return a func to stop it and record the duration; here it does nothing.
+func StartTimer(name string, ts opentsdb.TagSet) func() {
+	return func() {}
+}
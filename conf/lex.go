@@ -6,6 +6,11 @@ package conf
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -63,6 +68,41 @@ type lexer struct {
 	width   Pos       // width of last rune read from input
 	lastPos Pos       // position of most recent item returned by nextItem
 	items   chan item // channel of scanned items
+
+	// includeStack holds the enclosing files' state while an `include`
+	// directive is being spliced in; lexSpace pops it on EOF instead of
+	// emitting itemEOF. openFiles is the set of absolute paths currently
+	// being read, across the whole stack, used to reject include cycles.
+	includeStack []includeFrame
+	openFiles    map[string]bool
+
+	// inSection is true once a `[section]` header has been lexed, so
+	// lexValue knows a literal `include` key inside the section body is an
+	// ordinary value, not a directive: `include` only splices files at the
+	// top level, before any section.
+	inSection bool
+}
+
+// includeFrame is the resume point for the file an `include` directive was
+// found in, plus the sibling files still queued from that same directive
+// (a glob can match more than one) and the absolute paths opened so far,
+// which popInclude removes from openFiles once the whole directive is done.
+type includeFrame struct {
+	name   string
+	input  string
+	pos    Pos
+	start  Pos
+	queue  []includeFile
+	opened []string
+}
+
+// includeFile is one file matched by an `include` directive, resolved to
+// its absolute path up front so nested relative includes inside it resolve
+// against where it actually lives, not against the pattern text that found
+// it.
+type includeFile struct {
+	abs  string
+	data string
 }
 
 // next returns the next rune in the input.
@@ -125,8 +165,16 @@ func (l *lexer) lineNumber() int {
 
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.nextItem.
+// The message is prefixed with the name and line of the file currently
+// being scanned, so an error inside an `include`d file still points back
+// to it rather than the top-level config.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- item{itemError, l.start, fmt.Sprintf(format, args...)}
+	msg := fmt.Sprintf(format, args...)
+	if l.name != "" {
+		line := 1 + strings.Count(l.input[:l.start], "\n")
+		msg = fmt.Sprintf("%s:%d: %s", l.name, line, msg)
+	}
+	l.items <- item{itemError, l.start, msg}
 	return nil
 }
 
@@ -137,12 +185,19 @@ func (l *lexer) nextItem() item {
 	return item
 }
 
-// lex creates a new scanner for the input string.
+// lex creates a new scanner for the input string. name is resolved to an
+// absolute path up front (when possible) so that an `include` found at any
+// depth resolves relative paths against where its containing file actually
+// lives, regardless of the process's current directory.
 func lex(name, input string) *lexer {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
 	l := &lexer{
-		name:  name,
-		input: input,
-		items: make(chan item),
+		name:      name,
+		input:     input,
+		items:     make(chan item),
+		openFiles: map[string]bool{name: true},
 	}
 	go l.run()
 	return l
@@ -175,6 +230,9 @@ Loop:
 		case isSpace(r) || isEndOfLine(r):
 			// ignore
 		case r == eof:
+			if l.popInclude() {
+				continue
+			}
 			l.emit(itemEOF)
 			break Loop
 		default:
@@ -208,6 +266,7 @@ func lexVarname(l *lexer) stateFn {
 func lexRightDelim(l *lexer) stateFn {
 	l.pos += Pos(len(string(rightDelim)))
 	l.emit(itemRightDelim)
+	l.inSection = true
 	return lexSpace
 }
 
@@ -219,12 +278,122 @@ func lexValue(l *lexer) stateFn {
 			// absorb
 		default:
 			l.backup()
+			if !l.inSection && l.input[l.start:l.pos] == "include" {
+				l.ignore()
+				return lexIncludeDirective
+			}
 			l.emit(itemIdentifier)
 			return lexEqual
 		}
 	}
 }
 
+// lexIncludeDirective scans the quoted path/glob of an `include` directive
+// and splices the matched file(s) in. l.start is positioned right after the
+// "include" keyword.
+func lexIncludeDirective(l *lexer) stateFn {
+	for isSpace(l.peek()) {
+		l.next()
+	}
+	l.ignore()
+	if l.next() != '"' {
+		return l.errorf("include: expected a quoted path")
+	}
+	l.ignore()
+	for {
+		switch r := l.next(); {
+		case r == '"':
+			l.backup()
+			pattern := l.input[l.start:l.pos]
+			l.next()
+			l.ignore()
+			return l.doInclude(pattern)
+		case r == eof || isEndOfLine(r):
+			return l.errorf("include: unterminated quoted path")
+		}
+	}
+}
+
+// doInclude resolves pattern (relative to the including file's directory,
+// unless absolute) against the filesystem, checks each match against
+// openFiles to reject include cycles, and switches the lexer to the first
+// match, queuing any siblings a glob matched to be read in turn. Each match
+// is read under its own resolved absolute path, so a relative `include`
+// found inside it resolves against the directory it actually lives in
+// rather than against the pattern text that found it. lexSpace resumes the
+// including file once the whole directive's files are exhausted.
+func (l *lexer) doInclude(pattern string) stateFn {
+	glob := pattern
+	if !filepath.IsAbs(pattern) {
+		if dir := filepath.Dir(l.name); dir != "" && dir != "." {
+			glob = filepath.Join(dir, pattern)
+		}
+	}
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return l.errorf("include %q: %v", pattern, err)
+	}
+	if len(matches) == 0 {
+		return l.errorf("include %q: no files matched", pattern)
+	}
+	sort.Strings(matches)
+
+	var files []includeFile
+	var opened []string
+	for _, m := range matches {
+		abs, err := filepath.Abs(m)
+		if err != nil {
+			return l.errorf("include %q: %v", m, err)
+		}
+		if l.openFiles[abs] {
+			return l.errorf("include %q: include cycle detected at %s", pattern, abs)
+		}
+		data, err := ioutil.ReadFile(m)
+		if err != nil {
+			return l.errorf("include %q: %v", m, err)
+		}
+		l.openFiles[abs] = true
+		opened = append(opened, abs)
+		files = append(files, includeFile{abs: abs, data: string(data)})
+	}
+
+	l.includeStack = append(l.includeStack, includeFrame{
+		name: l.name, input: l.input, pos: l.pos, start: l.start,
+		queue: files[1:], opened: opened,
+	})
+	l.enterInclude(files[0])
+	return lexSpace
+}
+
+// enterInclude points the lexer at f as its current input.
+func (l *lexer) enterInclude(f includeFile) {
+	l.name, l.input, l.pos, l.start = f.abs, f.data, 0, 0
+}
+
+// popInclude advances to the next file queued by the most recent `include`
+// directive, if any; once that queue is empty it restores the lexer to the
+// file that contained the directive and frees the paths it opened. It
+// reports false once the include stack is empty, meaning EOF really is EOF.
+func (l *lexer) popInclude() bool {
+	for len(l.includeStack) > 0 {
+		n := len(l.includeStack) - 1
+		frame := &l.includeStack[n]
+		if len(frame.queue) > 0 {
+			next := frame.queue[0]
+			frame.queue = frame.queue[1:]
+			l.enterInclude(next)
+			return true
+		}
+		l.includeStack = l.includeStack[:n]
+		for _, abs := range frame.opened {
+			delete(l.openFiles, abs)
+		}
+		l.name, l.input, l.pos, l.start = frame.name, frame.input, frame.pos, frame.start
+		return true
+	}
+	return false
+}
+
 func lexEqual(l *lexer) stateFn {
 Loop:
 	for {
@@ -252,7 +421,8 @@ func lexString(l *lexer) stateFn {
 	for {
 		switch r := l.next(); {
 		case isEndOfLine(r) || r == eof:
-			l.emit(itemString)
+			l.backup()
+			l.emitExpanded(itemString)
 			return lexSpace
 		}
 	}
@@ -269,10 +439,34 @@ Loop:
 			break Loop
 		}
 	}
-	l.emit(itemRawString)
+	l.emitExpanded(itemRawString)
 	return lexSpace
 }
 
+// envVarPattern matches ${NAME} and ${NAME:-default} for emitExpanded.
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// emitExpanded is like emit, but interpolates ${ENV_VAR} and
+// ${ENV_VAR:-default} references in the item's value against the process
+// environment before sending it. Used for itemString and itemRawString so
+// config values can resolve credentials at load time instead of embedding
+// them.
+func (l *lexer) emitExpanded(t itemType) {
+	val := envVarPattern.ReplaceAllStringFunc(l.input[l.start:l.pos], func(ref string) string {
+		m := envVarPattern.FindStringSubmatch(ref)
+		name, hasDefault, def := m[1], m[2] != "", m[3]
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		if hasDefault {
+			return def
+		}
+		return ""
+	})
+	l.items <- item{t, l.start, val}
+	l.start = l.pos
+}
+
 // isSpace reports whether r is a space character.
 func isSpace(r rune) bool {
 	return r == ' ' || r == '\t'
@@ -285,4 +479,4 @@ func isEndOfLine(r rune) bool {
 
 func isVarchar(r rune) bool {
 	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
-}
\ No newline at end of file
+}
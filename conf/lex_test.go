@@ -0,0 +1,116 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// collect drains l until itemEOF or itemError, returning every item seen
+// (itemEOF/itemError included, always last).
+func collect(l *lexer) []item {
+	var items []item
+	for {
+		it := l.nextItem()
+		items = append(items, it)
+		if it.typ == itemEOF || it.typ == itemError {
+			return items
+		}
+	}
+}
+
+func vals(items []item, typ itemType) []string {
+	var out []string
+	for _, it := range items {
+		if it.typ == typ {
+			out = append(out, it.val)
+		}
+	}
+	return out
+}
+
+func TestLexBasic(t *testing.T) {
+	items := collect(lex("test", "host = web01\n\n[graphite]\nhost = localhost\n"))
+	if last := items[len(items)-1]; last.typ != itemEOF {
+		t.Fatalf("expected itemEOF, got %v", last)
+	}
+	if got := vals(items, itemIdentifier); len(got) != 3 || got[0] != "host" || got[1] != "graphite" || got[2] != "host" {
+		t.Fatalf("unexpected identifiers: %v", got)
+	}
+	if got := vals(items, itemString); len(got) != 2 || got[0] != "web01" || got[1] != "localhost" {
+		t.Fatalf("unexpected strings: %v", got)
+	}
+}
+
+func TestLexIncludeNested(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "confd")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// top.conf (in root) includes confd/a.conf; a.conf (in confd/) includes
+	// b.conf via a path relative to *its own* directory (confd/), not
+	// root's. Resolving b.conf against root instead of confd would 404.
+	writeFile(t, filepath.Join(root, "top.conf"), `include "confd/a.conf"`)
+	writeFile(t, filepath.Join(sub, "a.conf"), "host = from-a\ninclude \"b.conf\"")
+	writeFile(t, filepath.Join(sub, "b.conf"), "host2 = from-b")
+
+	items := collect(lex(filepath.Join(root, "top.conf"), `include "confd/a.conf"`))
+	if last := items[len(items)-1]; last.typ != itemEOF {
+		t.Fatalf("expected itemEOF, got %v", last)
+	}
+	got := vals(items, itemString)
+	if len(got) != 2 || got[0] != "from-a" || got[1] != "from-b" {
+		t.Fatalf("nested relative include resolved wrong file(s), got %v", got)
+	}
+}
+
+func TestLexIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.conf"), `include "b.conf"`)
+	writeFile(t, filepath.Join(dir, "b.conf"), `include "a.conf"`)
+
+	items := collect(lex(filepath.Join(dir, "a.conf"), `include "b.conf"`))
+	last := items[len(items)-1]
+	if last.typ != itemError || !strings.Contains(last.val, "include cycle detected") {
+		t.Fatalf("expected include cycle error, got %v", last)
+	}
+}
+
+func TestLexIncludeKeyInSection(t *testing.T) {
+	// Once a [section] header has been lexed, a literal `include` key is
+	// an ordinary identifier, not a directive.
+	items := collect(lex("test", "[graphite]\ninclude = true\n"))
+	last := items[len(items)-1]
+	if last.typ != itemEOF {
+		t.Fatalf("expected itemEOF, got %v", last)
+	}
+	if got := vals(items, itemIdentifier); len(got) != 2 || got[1] != "include" {
+		t.Fatalf("expected include to lex as an identifier inside a section, got %v", got)
+	}
+	if got := vals(items, itemString); len(got) != 1 || got[0] != "true" {
+		t.Fatalf("expected include's value to lex as a string, got %v", got)
+	}
+}
+
+func TestLexEnvVarInterpolation(t *testing.T) {
+	os.Setenv("BOSUN_LEX_TEST_VAR", "secret")
+	defer os.Unsetenv("BOSUN_LEX_TEST_VAR")
+	os.Unsetenv("BOSUN_LEX_TEST_MISSING")
+
+	items := collect(lex("test", "a = ${BOSUN_LEX_TEST_VAR}\nb = ${BOSUN_LEX_TEST_MISSING:-fallback}\n"))
+	got := vals(items, itemString)
+	if len(got) != 2 || got[0] != "secret" || got[1] != "fallback" {
+		t.Fatalf("env var interpolation failed, got %v", got)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,50 @@
+// Package opentsdb is a minimal stand-in for Bosun's real OpenTSDB client.
+// cmd/bosun/search and cmd/bosun/database already imported bosun.org/opentsdb
+// in the baseline commit, before any work in this series touched them, but
+// no implementation of the package existed anywhere in this trimmed tree to
+// import. This defines only the types those packages reference; swap it out
+// once the full bosun.org/opentsdb client is available.
+package opentsdb
+
+import (
+	"sort"
+	"strings"
+)
+
+type TagSet map[string]string
+
+// String renders t as "{key=val,key2=val2}", keys in sorted order.
+func (t TagSet) String() string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + t[k]
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type DataPoint struct {
+	Metric    string
+	Timestamp int64
+	Value     interface{}
+	Tags      TagSet
+}
+
+type MultiDataPoint []*DataPoint
+
+type Query struct {
+	Metric string
+	Tags   TagSet
+}
+
+type Response struct {
+	Metric string
+	Tags   TagSet
+	DPS    map[string]interface{}
+}
+
+type ResponseSet []*Response
@@ -0,0 +1,14 @@
+// Package slog is a minimal stand-in for Bosun's real structured logger.
+// cmd/bosun/search already imported bosun.org/slog in the baseline commit,
+// before any work in this series touched it, but no implementation of the
+// package existed anywhere in this trimmed tree to import. Error here just
+// logs to the standard logger; swap this package out once the full
+// bosun.org/slog client is available.
+package slog
+
+import "log"
+
+// Error logs v as an error-level message.
+func Error(v ...interface{}) {
+	log.Println(v...)
+}